@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actor is the ActivityPub actor the poller publishes feed items as.
+type Actor struct {
+	Name        string
+	Domain      string // host the actor is served from, e.g. "example.com"
+	IRI         string
+	FeedURL     string
+	PublicKeyID string
+	PrivateKey  *rsa.PrivateKey
+}
+
+// Handle is the actor's acct: identifier, e.g. "name@example.com".
+func (a Actor) Handle() string {
+	return a.Name + "@" + a.Domain
+}
+
+// Follower is a remote actor who has subscribed to this Actor's feed.
+type Follower struct {
+	Actor string `json:"actor"`
+	Inbox string `json:"inbox"`
+}
+
+// FollowerStore persists followers and tracks which feed items have
+// already been delivered to them, so republishing is idempotent.
+type FollowerStore interface {
+	Followers(ctx context.Context) ([]Follower, error)
+	AddFollower(ctx context.Context, f Follower) error
+	RemoveFollower(ctx context.Context, actor string) error
+	HasSent(ctx context.Context, itemURL string) (bool, error)
+	MarkSent(ctx context.Context, itemURL string) error
+}
+
+// jsonFollowerState is the on-disk shape used by JSONFileFollowerStore.
+type jsonFollowerState struct {
+	Followers []Follower `json:"followers"`
+	Sent      []string   `json:"sent"`
+}
+
+// JSONFileFollowerStore is the default FollowerStore, backed by a single
+// JSON file on disk.
+type JSONFileFollowerStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewJSONFileFollowerStore(path string) *JSONFileFollowerStore {
+	return &JSONFileFollowerStore{path: path}
+}
+
+func (s *JSONFileFollowerStore) load() (jsonFollowerState, error) {
+	var state jsonFollowerState
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if len(data) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+func (s *JSONFileFollowerStore) save(state jsonFollowerState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o644)
+}
+
+func (s *JSONFileFollowerStore) Followers(ctx context.Context) ([]Follower, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return state.Followers, nil
+}
+
+func (s *JSONFileFollowerStore) AddFollower(ctx context.Context, f Follower) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	for _, existing := range state.Followers {
+		if existing.Actor == f.Actor {
+			return nil
+		}
+	}
+	state.Followers = append(state.Followers, f)
+	return s.save(state)
+}
+
+func (s *JSONFileFollowerStore) RemoveFollower(ctx context.Context, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := state.Followers[:0]
+	for _, existing := range state.Followers {
+		if existing.Actor != actor {
+			kept = append(kept, existing)
+		}
+	}
+	state.Followers = kept
+	return s.save(state)
+}
+
+func (s *JSONFileFollowerStore) HasSent(ctx context.Context, itemURL string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	for _, sent := range state.Sent {
+		if sent == itemURL {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *JSONFileFollowerStore) MarkSent(ctx context.Context, itemURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	state.Sent = append(state.Sent, itemURL)
+	return s.save(state)
+}
+
+// ActivityPubService serves the actor's ActivityPub endpoints and fans
+// out new feed items to followers as signed Create activities.
+type ActivityPubService struct {
+	Actor      Actor
+	Store      FollowerStore
+	HTTPClient httpDoer
+}
+
+// asActor is the AS2 representation served at /actor/{name}.
+type asActor struct {
+	Context           interface{} `json:"@context"`
+	ID                string      `json:"id"`
+	Type              string      `json:"type"`
+	PreferredUsername string      `json:"preferredUsername"`
+	Inbox             string      `json:"inbox"`
+	Outbox            string      `json:"outbox"`
+	PublicKey         asPublicKey `json:"publicKey"`
+}
+
+type asPublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type webfingerResponse struct {
+	Subject string          `json:"subject"`
+	Links   []webfingerLink `json:"links"`
+}
+
+type webfingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type"`
+	Href string `json:"href"`
+}
+
+// ServeActor responds with the actor document for the configured Actor.
+func (a *ActivityPubService) ServeActor(w http.ResponseWriter, r *http.Request) {
+	doc := asActor{
+		Context:           []string{"https://www.w3.org/ns/activitystreams"},
+		ID:                a.Actor.IRI,
+		Type:              "Person",
+		PreferredUsername: a.Actor.Name,
+		Inbox:             a.Actor.IRI + "/inbox",
+		Outbox:            a.Actor.IRI + "/outbox",
+		PublicKey: asPublicKey{
+			ID:           a.Actor.PublicKeyID,
+			Owner:        a.Actor.IRI,
+			PublicKeyPem: publicKeyToPEM(&a.Actor.PrivateKey.PublicKey),
+		},
+	}
+	w.Header().Set("Content-Type", "application/activity+json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+// ServeWebFinger resolves acct: resources to the actor IRI.
+func (a *ActivityPubService) ServeWebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource != "acct:"+a.Actor.Handle() && resource != a.Actor.IRI {
+		http.NotFound(w, r)
+		return
+	}
+	resp := webfingerResponse{
+		Subject: "acct:" + a.Actor.Handle(),
+		Links: []webfingerLink{
+			{Rel: "self", Type: "application/activity+json", Href: a.Actor.IRI},
+		},
+	}
+	w.Header().Set("Content-Type", "application/jrd+json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// inboxActivity is the subset of an incoming activity we need to route it.
+type inboxActivity struct {
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// ServeInbox accepts Follow/Undo/Delete activities from remote actors.
+func (a *ActivityPubService) ServeInbox(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var activity inboxActivity
+	if err := json.NewDecoder(r.Body).Decode(&activity); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	switch activity.Type {
+	case "Follow":
+		inbox, err := a.resolveInbox(ctx, activity.Actor)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if err := a.Store.AddFollower(ctx, Follower{Actor: activity.Actor, Inbox: inbox}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		go a.sendAccept(activity)
+	case "Undo", "Delete":
+		if err := a.Store.RemoveFollower(ctx, activity.Actor); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendAccept sends an Accept activity back in response to a Follow. Best
+// effort: failures are not surfaced since the follower has already been
+// recorded.
+func (a *ActivityPubService) sendAccept(follow inboxActivity) {
+	ctx := context.Background()
+	inbox, err := a.resolveInbox(ctx, follow.Actor)
+	if err != nil {
+		return
+	}
+	accept := map[string]interface{}{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"type":     "Accept",
+		"actor":    a.Actor.IRI,
+		"object": map[string]interface{}{
+			"type":   "Follow",
+			"actor":  follow.Actor,
+			"object": a.Actor.IRI,
+		},
+	}
+	body, err := json.Marshal(accept)
+	if err != nil {
+		return
+	}
+	a.deliver(ctx, inbox, body)
+}
+
+// resolveInbox fetches a remote actor document and returns its inbox URL.
+func (a *ActivityPubService) resolveInbox(ctx context.Context, actorIRI string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", actorIRI, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/activity+json")
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var doc asActor
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", err
+	}
+	if doc.Inbox == "" {
+		return "", fmt.Errorf("activitypub: actor %s has no inbox", actorIRI)
+	}
+	return doc.Inbox, nil
+}
+
+// Publish wraps a feed item as a Create activity and delivers it to every
+// follower's inbox. Already-delivered items are skipped so the caller can
+// safely call Publish again for the same item.
+func (a *ActivityPubService) Publish(ctx context.Context, item FeedItem) error {
+	sent, err := a.Store.HasSent(ctx, item.URL)
+	if err != nil {
+		return err
+	}
+	if sent {
+		return nil
+	}
+
+	followers, err := a.Store.Followers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(followers) == 0 {
+		return a.Store.MarkSent(ctx, item.URL)
+	}
+
+	body, err := json.Marshal(a.buildCreateActivity(item))
+	if err != nil {
+		return err
+	}
+
+	// Delivery to an individual follower's inbox is best-effort: one
+	// unreachable inbox must not turn into a hard failure for the whole
+	// item: MarkSent below records the item as delivered either way, and
+	// the poller has already marked it seen before publishing.
+	for _, f := range followers {
+		if err := a.deliver(ctx, f.Inbox, body); err != nil {
+			log.Printf("activitypub: deliver %s to %s: %v", item.URL, f.Inbox, err)
+		}
+	}
+
+	return a.Store.MarkSent(ctx, item.URL)
+}
+
+// buildCreateActivity wraps a feed item as an AS2 Note inside a Create
+// activity addressed to the actor's followers and the public collection.
+func (a *ActivityPubService) buildCreateActivity(item FeedItem) map[string]interface{} {
+	published := item.Published.Format(time.RFC3339)
+	content := item.Content
+	if content == "" {
+		content = item.URL
+	}
+
+	to := []string{"https://www.w3.org/ns/activitystreams#Public", a.Actor.IRI + "/followers"}
+
+	note := map[string]interface{}{
+		"id":           item.URL,
+		"type":         "Note",
+		"published":    published,
+		"attributedTo": a.Actor.IRI,
+		"name":         item.Title,
+		"content":      content,
+		"to":           to,
+	}
+
+	return map[string]interface{}{
+		"@context":  "https://www.w3.org/ns/activitystreams",
+		"id":        item.URL + "#create",
+		"type":      "Create",
+		"actor":     a.Actor.IRI,
+		"published": published,
+		"to":        to,
+		"object":    note,
+	}
+}
+
+// deliver POSTs an already-marshaled activity to a follower's inbox,
+// signed per draft-cavage-http-signatures.
+func (a *ActivityPubService) deliver(ctx context.Context, inbox string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+
+	if err := signRequest(req, body, a.Actor.PublicKeyID, a.Actor.PrivateKey); err != nil {
+		return err
+	}
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("activitypub: inbox %s returned status %d", inbox, resp.StatusCode)
+	}
+	return nil
+}
+
+// signRequest signs req per draft-cavage-http-signatures using the
+// "(request-target) host date digest" header set.
+func signRequest(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	digest := sha256.Sum256(body)
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	signingString := strings.Join([]string{
+		"(request-target): " + strings.ToLower(req.Method) + " " + req.URL.RequestURI(),
+		"host: " + req.Host,
+		"date: " + req.Header.Get("Date"),
+		"digest: " + req.Header.Get("Digest"),
+	}, "\n")
+
+	hashed := sha256.Sum256([]byte(signingString))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(
+		`keyId="%s",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="%s"`,
+		keyID, base64.StdEncoding.EncodeToString(signature),
+	))
+	return nil
+}
+
+// RegisterHandlers wires the actor, WebFinger, and inbox endpoints onto mux.
+func (a *ActivityPubService) RegisterHandlers(mux *http.ServeMux) {
+	actorPath := "/actor/" + a.Actor.Name
+	mux.HandleFunc(actorPath, a.ServeActor)
+	mux.HandleFunc(actorPath+"/inbox", a.ServeInbox)
+	mux.HandleFunc("/.well-known/webfinger", a.ServeWebFinger)
+}
+
+// publicKeyToPEM encodes pub as SPKI PEM, the form ActivityPub peers
+// (Mastodon et al.) expect in an actor's publicKeyPem.
+func publicKeyToPEM(pub *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	block := &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	return string(pem.EncodeToMemory(block))
+}
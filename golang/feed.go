@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Feed is the unified representation of a feed, independent of whether
+// it arrived as JSON Feed, Atom, or RSS.
+type Feed struct {
+	Title string
+	Items []FeedItem
+}
+
+// FeedItem is the unified representation of a single feed entry. Dates
+// are normalized to time.Time so downstream consumers (SNS, ActivityPub,
+// webmentions) see a stable schema regardless of source format.
+type FeedItem struct {
+	URL       string
+	GUID      string
+	Published time.Time
+	Title     string
+	Content   string
+}
+
+// FeedParser parses raw feed bytes into the unified Feed model.
+type FeedParser interface {
+	Parse(data []byte) (*Feed, error)
+}
+
+// ParseFeed dispatches to the right FeedParser for contentType, falling
+// back to sniffing the first non-whitespace byte of data when
+// contentType is empty or unrecognized.
+func ParseFeed(contentType string, data []byte) (*Feed, error) {
+	parser, err := parserFor(contentType, data)
+	if err != nil {
+		return nil, err
+	}
+	return parser.Parse(data)
+}
+
+func parserFor(contentType string, data []byte) (FeedParser, error) {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		return JSONFeedParser{}, nil
+	case strings.Contains(ct, "atom"):
+		return AtomFeedParser{}, nil
+	case strings.Contains(ct, "rss"):
+		return RSSFeedParser{}, nil
+	case strings.Contains(ct, "xml"):
+		return sniffXML(data)
+	}
+	return sniffContent(data)
+}
+
+// sniffContent is the fallback used when Content-Type is missing or
+// doesn't name a known feed format: it looks at the first non-whitespace
+// byte of the body.
+func sniffContent(data []byte) (FeedParser, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("feed: empty response body")
+	}
+	switch trimmed[0] {
+	case '{':
+		return JSONFeedParser{}, nil
+	case '<':
+		return sniffXML(data)
+	}
+	return nil, fmt.Errorf("feed: unrecognized feed format")
+}
+
+// sniffXML distinguishes Atom from RSS by the document's root element.
+func sniffXML(data []byte) (FeedParser, error) {
+	var probe struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	switch probe.XMLName.Local {
+	case "feed":
+		return AtomFeedParser{}, nil
+	case "rss":
+		return RSSFeedParser{}, nil
+	}
+	return nil, fmt.Errorf("feed: unrecognized XML root element %q", probe.XMLName.Local)
+}
+
+// parseFlexibleTime parses the handful of date formats the supported
+// feed formats use (RFC3339 for JSON Feed/Atom, RFC1123Z for RSS).
+// Unparsable or missing dates return a zero time.Time and an error; the
+// parsers below ignore that error, so a single bad item doesn't fail the
+// whole feed.
+func parseFlexibleTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("feed: empty date")
+	}
+	for _, layout := range []string{time.RFC3339, time.RFC3339Nano, time.RFC1123Z, time.RFC1123} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("feed: unrecognized date format %q", s)
+}
+
+// JSONFeedParser parses JSON Feed (https://www.jsonfeed.org/) documents.
+type JSONFeedParser struct{}
+
+func (JSONFeedParser) Parse(data []byte) (*Feed, error) {
+	var raw JsonFeed
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw.ToFeed(), nil
+}
+
+// ToFeed converts a JsonFeed into the unified Feed model. An item missing
+// "id" falls back to its url as the GUID, per the JSON Feed spec.
+func (f *JsonFeed) ToFeed() *Feed {
+	feed := &Feed{Title: f.Title}
+	for _, item := range f.Items {
+		guid := item.Id
+		if guid == "" {
+			guid = item.Url
+		}
+		published, _ := parseFlexibleTime(item.Date)
+		feed.Items = append(feed.Items, FeedItem{
+			URL:       item.Url,
+			GUID:      guid,
+			Published: published,
+			Title:     item.Title,
+			Content:   item.ContentText,
+		})
+	}
+	return feed
+}
+
+// AtomFeedParser parses Atom 1.0 (RFC 4287) documents.
+type AtomFeedParser struct{}
+
+type atomFeedXML struct {
+	XMLName xml.Name       `xml:"feed"`
+	Title   string         `xml:"title"`
+	Entries []atomEntryXML `xml:"entry"`
+}
+
+type atomEntryXML struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Published string        `xml:"published"`
+	Updated   string        `xml:"updated"`
+	Links     []atomLinkXML `xml:"link"`
+	Content   string        `xml:"content"`
+	Summary   string        `xml:"summary"`
+}
+
+type atomLinkXML struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+func (AtomFeedParser) Parse(data []byte) (*Feed, error) {
+	var raw atomFeedXML
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{Title: raw.Title}
+	for _, entry := range raw.Entries {
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+		t, _ := parseFlexibleTime(published)
+
+		content := entry.Content
+		if content == "" {
+			content = entry.Summary
+		}
+
+		feed.Items = append(feed.Items, FeedItem{
+			URL:       atomEntryURL(entry.Links),
+			GUID:      entry.ID,
+			Published: t,
+			Title:     entry.Title,
+			Content:   content,
+		})
+	}
+	return feed, nil
+}
+
+// atomEntryURL returns the entry's first alternate link (a link with no
+// rel counts as "alternate"), or its first link if there is none.
+func atomEntryURL(links []atomLinkXML) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// RSSFeedParser parses RSS 2.0 documents.
+type RSSFeedParser struct{}
+
+type rssFeedXML struct {
+	XMLName xml.Name      `xml:"rss"`
+	Channel rssChannelXML `xml:"channel"`
+}
+
+type rssChannelXML struct {
+	Title string       `xml:"title"`
+	Items []rssItemXML `xml:"item"`
+}
+
+type rssItemXML struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+}
+
+func (RSSFeedParser) Parse(data []byte) (*Feed, error) {
+	var raw rssFeedXML
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{Title: raw.Channel.Title}
+	for _, item := range raw.Channel.Items {
+		t, _ := parseFlexibleTime(item.PubDate)
+		guid := item.GUID
+		if guid == "" {
+			guid = item.Link
+		}
+		feed.Items = append(feed.Items, FeedItem{
+			URL:       item.Link,
+			GUID:      guid,
+			Published: t,
+			Title:     item.Title,
+			Content:   item.Description,
+		})
+	}
+	return feed, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFeed(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		wantTitle   string
+		wantItems   []FeedItem
+	}{
+		{
+			name:        "json feed by content-type",
+			contentType: "application/feed+json",
+			body: `{
+				"title": "Example",
+				"items": [
+					{"url": "https://example.com/1", "id": "1", "date_published": "2020-01-02T15:04:05Z"}
+				]
+			}`,
+			wantTitle: "Example",
+			wantItems: []FeedItem{
+				{URL: "https://example.com/1", GUID: "1", Published: time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)},
+			},
+		},
+		{
+			name:        "json feed missing id falls back to url as guid",
+			contentType: "application/json",
+			body: `{
+				"items": [
+					{"url": "https://example.com/2", "date_published": "2020-01-02T15:04:05Z"}
+				]
+			}`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/2", GUID: "https://example.com/2", Published: time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)},
+			},
+		},
+		{
+			name:        "json feed malformed date yields zero time, not an error",
+			contentType: "application/json",
+			body: `{
+				"items": [
+					{"url": "https://example.com/3", "id": "3", "date_published": "not-a-date"}
+				]
+			}`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/3", GUID: "3", Published: time.Time{}},
+			},
+		},
+		{
+			name:        "json feed sniffed without content-type",
+			contentType: "",
+			body:        `{"items": [{"url": "https://example.com/4", "id": "4", "date_published": "2020-01-02T15:04:05Z"}]}`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/4", GUID: "4", Published: time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)},
+			},
+		},
+		{
+			name:        "atom feed by content-type",
+			contentType: "application/atom+xml",
+			body: `<?xml version="1.0"?>
+				<feed xmlns="http://www.w3.org/2005/Atom">
+					<title>Example Atom</title>
+					<entry>
+						<id>tag:example.com,2020:1</id>
+						<title>First post</title>
+						<published>2020-02-03T10:00:00Z</published>
+						<link href="https://example.com/atom/1" rel="alternate"/>
+						<content>hello</content>
+					</entry>
+				</feed>`,
+			wantTitle: "Example Atom",
+			wantItems: []FeedItem{
+				{URL: "https://example.com/atom/1", GUID: "tag:example.com,2020:1", Title: "First post", Content: "hello", Published: time.Date(2020, 2, 3, 10, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:        "atom feed falls back to updated when published is absent",
+			contentType: "application/atom+xml",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+				<entry>
+					<id>tag:example.com,2020:2</id>
+					<updated>2020-03-04T11:00:00Z</updated>
+					<link href="https://example.com/atom/2"/>
+				</entry>
+			</feed>`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/atom/2", GUID: "tag:example.com,2020:2", Published: time.Date(2020, 3, 4, 11, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:        "atom feed sniffed from xml without content-type",
+			contentType: "",
+			body: `<feed xmlns="http://www.w3.org/2005/Atom">
+				<entry>
+					<id>tag:example.com,2020:3</id>
+					<published>2020-04-05T12:00:00Z</published>
+					<link href="https://example.com/atom/3"/>
+				</entry>
+			</feed>`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/atom/3", GUID: "tag:example.com,2020:3", Published: time.Date(2020, 4, 5, 12, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:        "rss feed by content-type",
+			contentType: "application/rss+xml",
+			body: `<rss version="2.0">
+				<channel>
+					<title>Example RSS</title>
+					<item>
+						<title>RSS post</title>
+						<link>https://example.com/rss/1</link>
+						<guid>rss-1</guid>
+						<pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+						<description>body</description>
+					</item>
+				</channel>
+			</rss>`,
+			wantTitle: "Example RSS",
+			wantItems: []FeedItem{
+				{URL: "https://example.com/rss/1", GUID: "rss-1", Title: "RSS post", Content: "body", Published: time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))},
+			},
+		},
+		{
+			name:        "rss feed missing guid falls back to link",
+			contentType: "application/rss+xml",
+			body: `<rss version="2.0">
+				<channel>
+					<item>
+						<link>https://example.com/rss/2</link>
+						<pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+					</item>
+				</channel>
+			</rss>`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/rss/2", GUID: "https://example.com/rss/2", Published: time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))},
+			},
+		},
+		{
+			name:        "rss feed malformed pubDate yields zero time, not an error",
+			contentType: "application/rss+xml",
+			body: `<rss version="2.0">
+				<channel>
+					<item>
+						<link>https://example.com/rss/3</link>
+						<guid>rss-3</guid>
+						<pubDate>not a date</pubDate>
+					</item>
+				</channel>
+			</rss>`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/rss/3", GUID: "rss-3", Published: time.Time{}},
+			},
+		},
+		{
+			name:        "rss feed sniffed from xml without content-type",
+			contentType: "",
+			body: `<rss version="2.0">
+				<channel>
+					<item>
+						<link>https://example.com/rss/4</link>
+						<guid>rss-4</guid>
+						<pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+					</item>
+				</channel>
+			</rss>`,
+			wantItems: []FeedItem{
+				{URL: "https://example.com/rss/4", GUID: "rss-4", Published: time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			feed, err := ParseFeed(tc.contentType, []byte(tc.body))
+			if err != nil {
+				t.Fatalf("ParseFeed() error = %v", err)
+			}
+			if feed.Title != tc.wantTitle {
+				t.Errorf("Title = %q, want %q", feed.Title, tc.wantTitle)
+			}
+			if len(feed.Items) != len(tc.wantItems) {
+				t.Fatalf("got %d items, want %d", len(feed.Items), len(tc.wantItems))
+			}
+			for i, got := range feed.Items {
+				want := tc.wantItems[i]
+				if got.URL != want.URL {
+					t.Errorf("item %d URL = %q, want %q", i, got.URL, want.URL)
+				}
+				if got.GUID != want.GUID {
+					t.Errorf("item %d GUID = %q, want %q", i, got.GUID, want.GUID)
+				}
+				if got.Title != want.Title {
+					t.Errorf("item %d Title = %q, want %q", i, got.Title, want.Title)
+				}
+				if got.Content != want.Content {
+					t.Errorf("item %d Content = %q, want %q", i, got.Content, want.Content)
+				}
+				if !got.Published.Equal(want.Published) {
+					t.Errorf("item %d Published = %v, want %v", i, got.Published, want.Published)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedUnrecognized(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{name: "empty body", contentType: "", body: ""},
+		{name: "whitespace-only body", contentType: "", body: "   \n\t"},
+		{name: "neither json nor xml", contentType: "", body: "plain text"},
+		{name: "unknown xml root element", contentType: "", body: "<foo></foo>"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseFeed(tc.contentType, []byte(tc.body)); err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseFlexibleTime(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "rfc3339", input: "2020-01-02T15:04:05Z", want: time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{name: "rfc1123z", input: "Mon, 02 Jan 2006 15:04:05 -0700", want: time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*60*60))},
+		{name: "empty", input: "", wantErr: true},
+		{name: "garbage", input: "not-a-date", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFlexibleTime(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("parseFlexibleTime(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
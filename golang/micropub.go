@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// PollerServer exposes a small Micropub-style query API so operators can
+// inspect the poller's configuration and state over HTTP instead of
+// reading logs or SNS.
+type PollerServer struct {
+	Poller     *Poller
+	Service    *MicroService
+	Webmention *WebmentionService
+	Token      string
+}
+
+func NewPollerServer(poller *Poller, service *MicroService, webmention *WebmentionService, token string) *PollerServer {
+	return &PollerServer{Poller: poller, Service: service, Webmention: webmention, Token: token}
+}
+
+// RegisterHandlers wires the /poller endpoint onto mux.
+func (s *PollerServer) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/poller", s.serve)
+}
+
+func (s *PollerServer) serve(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Query().Get("q") {
+	case "config":
+		s.serveConfig(w, r)
+	case "source":
+		s.serveSource(w, r)
+	case "syndicate-to":
+		s.serveSyndicateTo(w, r)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (s *PollerServer) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return false
+	}
+
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+
+	provided := auth[len(prefix):]
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(s.Token)) == 1
+}
+
+type configFeed struct {
+	URL      string `json:"url"`
+	Interval string `json:"interval"`
+}
+
+type configResponse struct {
+	Feeds       []configFeed `json:"feeds"`
+	SyndicateTo []string     `json:"syndicate-to"`
+}
+
+func (s *PollerServer) serveConfig(w http.ResponseWriter, r *http.Request) {
+	resp := configResponse{SyndicateTo: s.syndicateTargets()}
+	for _, feed := range s.Poller.Feeds {
+		resp.Feeds = append(resp.Feeds, configFeed{URL: feed.URL, Interval: feed.Interval.String()})
+	}
+	writeJSON(w, resp)
+}
+
+type sourceResponse struct {
+	URL          string `json:"url"`
+	ContentType  string `json:"content_type"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Contents     string `json:"contents"`
+}
+
+func (s *PollerServer) serveSource(w http.ResponseWriter, r *http.Request) {
+	feedURL := r.URL.Query().Get("url")
+	if feedURL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cached, ok := s.Poller.LastFetch(feedURL)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, sourceResponse{
+		URL:          feedURL,
+		ContentType:  cached.ContentType,
+		ETag:         cached.State.ETag,
+		LastModified: cached.State.LastModified,
+		Contents:     string(cached.Body),
+	})
+}
+
+func (s *PollerServer) serveSyndicateTo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string][]string{"syndicate-to": s.syndicateTargets()})
+}
+
+// syndicateTargets lists the downstream sinks a newly observed item is
+// currently fanned out to.
+func (s *PollerServer) syndicateTargets() []string {
+	var targets []string
+	if s.Service.TopicArn != "" {
+		targets = append(targets, "sns:"+s.Service.TopicArn)
+	}
+	if s.Service.ActivityPub != nil {
+		targets = append(targets, "activitypub:"+s.Service.ActivityPub.Actor.IRI)
+	}
+	if s.Webmention != nil {
+		targets = append(targets, "webmention")
+	}
+	return targets
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
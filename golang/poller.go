@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// FeedConfig is one feed the Poller watches, with its own poll interval.
+type FeedConfig struct {
+	URL      string
+	Interval time.Duration
+}
+
+// FeedState is the conditional-GET cache data kept per feed URL.
+type FeedState struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+// FeedStateStore persists per-feed conditional-GET state and the set of
+// item URLs already seen for that feed, so restarts don't re-emit old
+// items.
+type FeedStateStore interface {
+	GetState(ctx context.Context, feedURL string) (FeedState, error)
+	SaveState(ctx context.Context, feedURL string, state FeedState) error
+	Seen(ctx context.Context, feedURL, itemURL string) (bool, error)
+	MarkSeen(ctx context.Context, feedURL, itemURL string) error
+}
+
+// MemoryFeedStateStore is a FeedStateStore that keeps everything in
+// process memory. State does not survive a restart.
+type MemoryFeedStateStore struct {
+	mu     sync.Mutex
+	states map[string]FeedState
+	seen   map[string]map[string]bool
+}
+
+func NewMemoryFeedStateStore() *MemoryFeedStateStore {
+	return &MemoryFeedStateStore{
+		states: make(map[string]FeedState),
+		seen:   make(map[string]map[string]bool),
+	}
+}
+
+func (m *MemoryFeedStateStore) GetState(ctx context.Context, feedURL string) (FeedState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states[feedURL], nil
+}
+
+func (m *MemoryFeedStateStore) SaveState(ctx context.Context, feedURL string, state FeedState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[feedURL] = state
+	return nil
+}
+
+func (m *MemoryFeedStateStore) Seen(ctx context.Context, feedURL, itemURL string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.seen[feedURL][itemURL], nil
+}
+
+func (m *MemoryFeedStateStore) MarkSeen(ctx context.Context, feedURL, itemURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seen[feedURL] == nil {
+		m.seen[feedURL] = make(map[string]bool)
+	}
+	m.seen[feedURL][itemURL] = true
+	return nil
+}
+
+// jsonFeedStateFile is the on-disk shape used by JSONFileFeedStateStore.
+type jsonFeedStateFile struct {
+	Feeds map[string]jsonFeedStateEntry `json:"feeds"`
+}
+
+type jsonFeedStateEntry struct {
+	State FeedState `json:"state"`
+	Seen  []string  `json:"seen"`
+}
+
+// JSONFileFeedStateStore is a FeedStateStore backed by a single JSON file.
+type JSONFileFeedStateStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewJSONFileFeedStateStore(path string) *JSONFileFeedStateStore {
+	return &JSONFileFeedStateStore{path: path}
+}
+
+func (f *JSONFileFeedStateStore) load() (jsonFeedStateFile, error) {
+	file := jsonFeedStateFile{Feeds: make(map[string]jsonFeedStateEntry)}
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return file, err
+	}
+	if len(data) == 0 {
+		return file, nil
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, err
+	}
+	if file.Feeds == nil {
+		file.Feeds = make(map[string]jsonFeedStateEntry)
+	}
+	return file, nil
+}
+
+func (f *JSONFileFeedStateStore) save(file jsonFeedStateFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0o644)
+}
+
+func (f *JSONFileFeedStateStore) GetState(ctx context.Context, feedURL string) (FeedState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.load()
+	if err != nil {
+		return FeedState{}, err
+	}
+	return file.Feeds[feedURL].State, nil
+}
+
+func (f *JSONFileFeedStateStore) SaveState(ctx context.Context, feedURL string, state FeedState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.load()
+	if err != nil {
+		return err
+	}
+	entry := file.Feeds[feedURL]
+	entry.State = state
+	file.Feeds[feedURL] = entry
+	return f.save(file)
+}
+
+func (f *JSONFileFeedStateStore) Seen(ctx context.Context, feedURL, itemURL string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.load()
+	if err != nil {
+		return false, err
+	}
+	for _, seen := range file.Feeds[feedURL].Seen {
+		if seen == itemURL {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *JSONFileFeedStateStore) MarkSeen(ctx context.Context, feedURL, itemURL string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.load()
+	if err != nil {
+		return err
+	}
+	entry := file.Feeds[feedURL]
+	entry.Seen = append(entry.Seen, itemURL)
+	file.Feeds[feedURL] = entry
+	return f.save(file)
+}
+
+// FeedHealth reports the current polling status of a single feed.
+type FeedHealth struct {
+	LastSuccess         time.Time
+	LastError           error
+	ConsecutiveFailures int
+	NextAttempt         time.Time
+}
+
+// NewItemFunc is called for every feed item a Poller observes for the
+// first time, before it is forwarded to MicroService.PublishItem.
+type NewItemFunc func(ctx context.Context, feedURL string, item FeedItem)
+
+const (
+	minBackoff = 0
+	maxBackoff = time.Hour
+)
+
+// Poller runs a long-lived, cancellable loop over a set of feeds,
+// fetching each on its own interval with conditional GETs and forwarding
+// newly observed items to MicroService.PublishItem and any subscribed
+// callbacks.
+type Poller struct {
+	Service *MicroService
+	Feeds   []FeedConfig
+	Store   FeedStateStore
+
+	hooksMu sync.Mutex
+	hooks   []NewItemFunc
+
+	healthMu sync.Mutex
+	health   map[string]FeedHealth
+
+	cacheMu sync.Mutex
+	cache   map[string]FetchCache
+}
+
+func NewPoller(service *MicroService, feeds []FeedConfig, store FeedStateStore) *Poller {
+	return &Poller{
+		Service: service,
+		Feeds:   feeds,
+		Store:   store,
+		health:  make(map[string]FeedHealth),
+		cache:   make(map[string]FetchCache),
+	}
+}
+
+// FetchCache is the last successful raw fetch of a feed, kept so the
+// Micropub-style query API can expose source inspection without
+// re-fetching.
+type FetchCache struct {
+	Body        []byte
+	ContentType string
+	State       FeedState
+	FetchedAt   time.Time
+}
+
+// LastFetch returns the most recent successful fetch of feedURL, if any.
+func (p *Poller) LastFetch(feedURL string) (FetchCache, bool) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	cached, ok := p.cache[feedURL]
+	return cached, ok
+}
+
+// OnNewItem registers a callback invoked for every item a feed exposes
+// for the first time.
+func (p *Poller) OnNewItem(fn NewItemFunc) {
+	p.hooksMu.Lock()
+	defer p.hooksMu.Unlock()
+	p.hooks = append(p.hooks, fn)
+}
+
+// Health returns a snapshot of each feed's current polling health, keyed
+// by feed URL.
+func (p *Poller) Health() map[string]FeedHealth {
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+	snapshot := make(map[string]FeedHealth, len(p.health))
+	for url, health := range p.health {
+		snapshot[url] = health
+	}
+	return snapshot
+}
+
+// Run polls every configured feed on its own goroutine until ctx is
+// canceled.
+func (p *Poller) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, feed := range p.Feeds {
+		wg.Add(1)
+		go func(feed FeedConfig) {
+			defer wg.Done()
+			p.pollFeed(ctx, feed)
+		}(feed)
+	}
+	wg.Wait()
+}
+
+func (p *Poller) pollFeed(ctx context.Context, feed FeedConfig) {
+	backoff := time.Duration(minBackoff)
+	for {
+		wait := feed.Interval
+		if backoff > 0 {
+			wait = backoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if err := p.pollOnce(ctx, feed); err != nil {
+			backoff = nextBackoff(backoff, feed.Interval)
+			p.recordFailure(feed.URL, err, time.Now().Add(backoff))
+		} else {
+			backoff = minBackoff
+			p.recordSuccess(feed.URL, time.Now().Add(feed.Interval))
+		}
+	}
+}
+
+func nextBackoff(current, base time.Duration) time.Duration {
+	next := current * 2
+	if next < base {
+		next = base
+	}
+	if next > maxBackoff {
+		next = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(next)/2 + 1))
+	return next/2 + jitter
+}
+
+func (p *Poller) recordSuccess(feedURL string, next time.Time) {
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+	p.health[feedURL] = FeedHealth{
+		LastSuccess: time.Now(),
+		NextAttempt: next,
+	}
+}
+
+func (p *Poller) recordFailure(feedURL string, err error, next time.Time) {
+	p.healthMu.Lock()
+	defer p.healthMu.Unlock()
+	health := p.health[feedURL]
+	health.LastError = err
+	health.ConsecutiveFailures++
+	health.NextAttempt = next
+	p.health[feedURL] = health
+}
+
+// pollOnce performs a single conditional fetch of feed, publishing any
+// items not previously seen.
+func (p *Poller) pollOnce(ctx context.Context, feed FeedConfig) error {
+	state, err := p.Store.GetState(ctx, feed.URL)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.conditionalFetch(ctx, feed.URL, state)
+	if err != nil {
+		return err
+	}
+	if result.notModified {
+		return nil
+	}
+
+	if err := p.Store.SaveState(ctx, feed.URL, result.state); err != nil {
+		return err
+	}
+
+	p.cacheMu.Lock()
+	p.cache[feed.URL] = FetchCache{
+		Body:        result.body,
+		ContentType: result.contentType,
+		State:       result.state,
+		FetchedAt:   time.Now(),
+	}
+	p.cacheMu.Unlock()
+
+	for _, item := range result.feed.Items {
+		key := item.URL
+		if key == "" {
+			key = item.GUID
+		}
+
+		seen, err := p.Store.Seen(ctx, feed.URL, key)
+		if err != nil {
+			return err
+		}
+		if seen {
+			continue
+		}
+
+		// Mark the item seen before publishing: SNS has no dedup of its
+		// own, so a publish error here must not cause the next tick to
+		// re-publish the same item.
+		if err := p.Store.MarkSeen(ctx, feed.URL, key); err != nil {
+			return err
+		}
+
+		p.hooksMu.Lock()
+		hooks := append([]NewItemFunc(nil), p.hooks...)
+		p.hooksMu.Unlock()
+		for _, hook := range hooks {
+			hook(ctx, feed.URL, item)
+		}
+
+		if err := p.Service.PublishItem(ctx, item); err != nil {
+			log.Printf("poller: publish %s from feed %s: %v", item.URL, feed.URL, err)
+		}
+	}
+
+	return nil
+}
+
+type conditionalFetchResult struct {
+	feed        *Feed
+	body        []byte
+	contentType string
+	state       FeedState
+	notModified bool
+}
+
+// conditionalFetch performs a GET against feedURL, sending If-None-Match
+// / If-Modified-Since from state when available, and returns the parsed
+// feed along with the response's cache validators.
+func (p *Poller) conditionalFetch(ctx context.Context, feedURL string, state FeedState) (conditionalFetchResult, error) {
+	timeout, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeout, "GET", feedURL, nil)
+	if err != nil {
+		return conditionalFetchResult{}, err
+	}
+	req.Header.Set("User-Agent", "Spirit Riot Poller (+https://github.com/tkrehbiel/spiritriot-poller-service)")
+	if state.ETag != "" {
+		req.Header.Set("If-None-Match", state.ETag)
+	}
+	if state.LastModified != "" {
+		req.Header.Set("If-Modified-Since", state.LastModified)
+	}
+
+	resp, err := p.Service.HTTPClient.Do(req)
+	if err != nil {
+		return conditionalFetchResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return conditionalFetchResult{state: state, notModified: true}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return conditionalFetchResult{}, fmt.Errorf("poller: %s returned status %d", feedURL, resp.StatusCode)
+	}
+
+	newState := FeedState{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return conditionalFetchResult{}, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	feed, err := ParseFeed(contentType, body)
+	if err != nil {
+		return conditionalFetchResult{}, err
+	}
+
+	return conditionalFetchResult{feed: feed, body: body, contentType: contentType, state: newState}, nil
+}
@@ -13,6 +13,11 @@ import (
 type MicroService struct {
 	HTTPClient httpDoer
 	SNSClient  snsPublisher
+	TopicArn   string
+
+	// ActivityPub, when set, federates new feed items to followers'
+	// inboxes alongside the SNS publish below.
+	ActivityPub *ActivityPubService
 }
 
 type httpDoer interface {
@@ -24,15 +29,22 @@ type snsPublisher interface {
 }
 
 type JsonFeed struct {
+	Title string         `json:"title"`
 	Items []JsonFeedItem `json:"items"`
 }
 
 type JsonFeedItem struct {
-	Url  string `json:"url"`
-	Date string `json:"date_published"`
+	Url         string `json:"url"`
+	Id          string `json:"id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	ContentText string `json:"content_text,omitempty"`
+	Date        string `json:"date_published"`
 }
 
-func (s *MicroService) GetFeed(ctx context.Context, url string) (*JsonFeed, error) {
+// GetFeed fetches url once and parses it into the unified Feed model,
+// dispatching on the response's Content-Type (with a sniffing fallback)
+// so JSON Feed, Atom, and RSS sources are all supported.
+func (s *MicroService) GetFeed(ctx context.Context, url string) (*Feed, error) {
 	timeout, cancel := context.WithTimeout(ctx, time.Second*3)
 	defer cancel()
 
@@ -46,16 +58,42 @@ func (s *MicroService) GetFeed(ctx context.Context, url string) (*JsonFeed, erro
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	var feed JsonFeed
-	if err := json.Unmarshal(body, &feed); err != nil {
-		return nil, err
+	return ParseFeed(resp.Header.Get("Content-Type"), body)
+}
+
+// PublishItem fans a newly discovered feed item out to every configured
+// sink: SNS, and ActivityPub followers if federation is enabled.
+func (s *MicroService) PublishItem(ctx context.Context, item FeedItem) error {
+	if s.SNSClient != nil {
+		message, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		input := &sns.PublishInput{
+			TopicArn: &s.TopicArn,
+			Message:  stringPtr(string(message)),
+		}
+		if _, err := s.SNSClient.Publish(ctx, input); err != nil {
+			return err
+		}
 	}
 
-	return &feed, nil
+	if s.ActivityPub != nil {
+		if err := s.ActivityPub.Publish(ctx, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func stringPtr(s string) *string {
+	return &s
 }
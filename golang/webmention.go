@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebmentionResult records the outcome of a single webmention send so
+// retries stay bounded and duplicates are avoided.
+type WebmentionResult struct {
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	Endpoint  string    `json:"endpoint"`
+	Status    int       `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebmentionStore tracks which source/target pairs have already been
+// sent a webmention.
+type WebmentionStore interface {
+	HasSent(ctx context.Context, source, target string) (bool, error)
+	Record(ctx context.Context, result WebmentionResult) error
+}
+
+// MemoryWebmentionStore is a WebmentionStore kept entirely in process
+// memory. State does not survive a restart.
+type MemoryWebmentionStore struct {
+	mu      sync.Mutex
+	results []WebmentionResult
+}
+
+func NewMemoryWebmentionStore() *MemoryWebmentionStore {
+	return &MemoryWebmentionStore{}
+}
+
+func (m *MemoryWebmentionStore) HasSent(ctx context.Context, source, target string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.results {
+		if r.Source == source && r.Target == target {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryWebmentionStore) Record(ctx context.Context, result WebmentionResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results = append(m.results, result)
+	return nil
+}
+
+// jsonWebmentionStateFile is the on-disk shape used by JSONFileWebmentionStore.
+type jsonWebmentionStateFile struct {
+	Results []WebmentionResult `json:"results"`
+}
+
+// JSONFileWebmentionStore is a WebmentionStore backed by a single JSON file.
+type JSONFileWebmentionStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewJSONFileWebmentionStore(path string) *JSONFileWebmentionStore {
+	return &JSONFileWebmentionStore{path: path}
+}
+
+func (f *JSONFileWebmentionStore) load() (jsonWebmentionStateFile, error) {
+	var file jsonWebmentionStateFile
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return file, err
+	}
+	if len(data) == 0 {
+		return file, nil
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, err
+	}
+	return file, nil
+}
+
+func (f *JSONFileWebmentionStore) save(file jsonWebmentionStateFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0o644)
+}
+
+func (f *JSONFileWebmentionStore) HasSent(ctx context.Context, source, target string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.load()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range file.Results {
+		if r.Source == source && r.Target == target {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *JSONFileWebmentionStore) Record(ctx context.Context, result WebmentionResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.load()
+	if err != nil {
+		return err
+	}
+	file.Results = append(file.Results, result)
+	return f.save(file)
+}
+
+// WebmentionService discovers and sends webmentions for the outbound
+// links found in newly observed feed items.
+type WebmentionService struct {
+	HTTPClient httpDoer
+	Store      WebmentionStore
+}
+
+// Handle implements NewItemFunc. Register it with Poller.OnNewItem to
+// send webmentions for every new item's outbound links.
+func (w *WebmentionService) Handle(ctx context.Context, feedURL string, item FeedItem) {
+	links, err := w.outboundLinks(ctx, item.URL)
+	if err != nil {
+		return
+	}
+	for _, target := range links {
+		w.sendMention(ctx, item.URL, target)
+	}
+}
+
+// outboundLinks fetches item's HTML and returns every absolute <a href>
+// target found in the body.
+func (w *WebmentionService) outboundLinks(ctx context.Context, itemURL string) ([]string, error) {
+	body, base, err := w.fetch(ctx, itemURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
+	for _, tag := range findTags(body, "a") {
+		href, ok := tagAttr(tag, "href")
+		if !ok {
+			continue
+		}
+		resolved, err := resolveURL(base, href)
+		if err != nil {
+			continue
+		}
+		links = append(links, resolved)
+	}
+	return links, nil
+}
+
+// sendMention discovers target's webmention endpoint, if any, and posts
+// source/target to it, recording the outcome.
+func (w *WebmentionService) sendMention(ctx context.Context, source, target string) {
+	sent, err := w.Store.HasSent(ctx, source, target)
+	if err != nil || sent {
+		return
+	}
+
+	endpoint, err := w.discoverEndpoint(ctx, target)
+	if err != nil || endpoint == "" {
+		return
+	}
+
+	status, err := w.post(ctx, endpoint, source, target)
+	if err != nil {
+		return
+	}
+
+	w.Store.Record(ctx, WebmentionResult{
+		Source:    source,
+		Target:    target,
+		Endpoint:  endpoint,
+		Status:    status,
+		Timestamp: time.Now(),
+	})
+}
+
+// discoverEndpoint finds target's webmention endpoint via the Link
+// response header first, then <link>/<a rel="webmention"> in the body.
+func (w *WebmentionService) discoverEndpoint(ctx context.Context, target string) (string, error) {
+	timeout, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeout, "GET", target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Spirit Riot Poller (+https://github.com/tkrehbiel/spiritriot-poller-service)")
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	base := target
+	if resp.Request != nil && resp.Request.URL != nil {
+		base = resp.Request.URL.String()
+	}
+
+	if endpoint := linkHeaderWebmention(resp.Header.Values("Link")); endpoint != "" {
+		return resolveURL(base, endpoint)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	for _, tag := range append(findTags(body, "link"), findTags(body, "a")...) {
+		rel, ok := tagAttr(tag, "rel")
+		if !ok || !hasToken(rel, "webmention") {
+			continue
+		}
+		href, ok := tagAttr(tag, "href")
+		if !ok {
+			continue
+		}
+		return resolveURL(base, href)
+	}
+
+	return "", nil
+}
+
+// post sends the webmention itself.
+func (w *WebmentionService) post(ctx context.Context, endpoint, source, target string) (int, error) {
+	timeout, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	form := url.Values{"source": {source}, "target": {target}}
+	req, err := http.NewRequestWithContext(timeout, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// fetch GETs target and returns the response body and the final (post-
+// redirect) URL to resolve relative links against.
+func (w *WebmentionService) fetch(ctx context.Context, target string) ([]byte, string, error) {
+	timeout, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(timeout, "GET", target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "Spirit Riot Poller (+https://github.com/tkrehbiel/spiritriot-poller-service)")
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	base := target
+	if resp.Request != nil && resp.Request.URL != nil {
+		base = resp.Request.URL.String()
+	}
+
+	return body, base, nil
+}
+
+func resolveURL(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+var (
+	tagRegexpCache = map[string]*regexp.Regexp{}
+	tagRegexpMu    sync.Mutex
+)
+
+// findTags returns every occurrence of <name ...> in html, opening tag
+// only, attributes intact.
+func findTags(html []byte, name string) []string {
+	tagRegexpMu.Lock()
+	re, ok := tagRegexpCache[name]
+	if !ok {
+		re = regexp.MustCompile(`(?is)<` + name + `\b[^>]*>`)
+		tagRegexpCache[name] = re
+	}
+	tagRegexpMu.Unlock()
+
+	return re.FindAllString(string(html), -1)
+}
+
+// tagAttr extracts the value of attr from a single opening tag.
+func tagAttr(tag, attr string) (string, bool) {
+	re := regexp.MustCompile(`(?i)\b` + attr + `\s*=\s*("([^"]*)"|'([^']*)'|([^\s>]+))`)
+	m := re.FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+	for _, g := range m[2:] {
+		if g != "" {
+			return g, true
+		}
+	}
+	return "", false
+}
+
+// hasToken reports whether space-separated values contains token,
+// case-insensitively (used for rel="... webmention ...").
+func hasToken(values, token string) bool {
+	for _, v := range strings.Fields(values) {
+		if strings.EqualFold(v, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkHeaderWebmention extracts the URL of a rel="webmention" entry from
+// Link response header values.
+func linkHeaderWebmention(headers []string) string {
+	re := regexp.MustCompile(`<([^>]+)>\s*;\s*rel\s*=\s*"?([^",;]+)"?`)
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			m := re.FindStringSubmatch(strings.TrimSpace(part))
+			if m == nil {
+				continue
+			}
+			if hasToken(m[2], "webmention") {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}